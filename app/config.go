@@ -4,9 +4,9 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
-	"io/ioutil"
 	"math/big"
 	"net/url"
 	"os"
@@ -18,12 +18,24 @@ import (
 
 	// a .env file is extremely useful during development
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/keratin/authn-server/data"
 	"github.com/keratin/authn-server/lib/oauth"
+	"github.com/keratin/authn-server/lib/oauth/oidc"
 	"github.com/keratin/authn-server/lib/route"
+	"github.com/keratin/authn-server/lib/trustedjwt"
 	"github.com/keratin/authn-server/ops"
+	"github.com/pkg/errors"
 	"golang.org/x/crypto/pbkdf2"
 )
 
+// OIDCProviderConfig describes a single generically-configured OIDC provider, as parsed from the
+// OIDC_PROVIDERS environment variable.
+type OIDCProviderConfig = oidc.Config
+
+// TrustedIssuerConfig describes a single external JWT issuer that machine users may authenticate
+// against, as parsed from the TRUSTED_JWT_ISSUERS environment variable.
+type TrustedIssuerConfig = trustedjwt.Config
+
 // Config is the full list of configuration settings for AuthN. It is typically populated by reading
 // environment variables.
 type Config struct {
@@ -40,6 +52,8 @@ type Config struct {
 	PasswordMinComplexity       int
 	RefreshTokenTTL             time.Duration
 	RedisURL                    *url.URL
+	SessionStore                data.SessionStoreBackend
+	EtcdURL                     *url.URL
 	DatabaseURL                 *url.URL
 	SessionCookieName           string
 	OAuthCookieName             string
@@ -49,6 +63,7 @@ type Config struct {
 	OAuthSigningKey             []byte
 	ResetTokenTTL               time.Duration
 	IdentitySigningKey          *private.Key
+	IdentitySigningKeyID        string
 	AuthNURL                    *url.URL
 	ForceSSL                    bool
 	MountedPath                 string
@@ -68,6 +83,11 @@ type Config struct {
 	GitHubOauthCredentials      *oauth.Credentials
 	FacebookOauthCredentials    *oauth.Credentials
 	DiscordOauthCredentials     *oauth.Credentials
+	BitbucketOauthCredentials   *oauth.Credentials
+	OIDCProviders               []OIDCProviderConfig
+	TrustedJWTIssuers           []TrustedIssuerConfig
+	SecretsReload               bool
+	RedirectWhitelist           []route.Domain
 }
 
 var configurers = []configurer{
@@ -119,25 +139,18 @@ var configurers = []configurer{
 	// But it does help in case the key base has less entropy than might be ideal,
 	// and it does protect from escalating an attack on one derived key into an
 	// attack on all of the derived keys.
+	//
+	// Like every other credential-bearing setting, it may instead be provided via a mounted secret
+	// file named by SECRET_KEY_BASE_FILE.
 	func(c *Config) error {
-		fileName, err := requireEnv("SECRET_KEY_BASE_FILE")
-		if err != nil {
-			fmt.Printf("Failed to load SECRET_KEY_BASE_FILE: %v", err)
-			val, err := requireEnv("SECRET_KEY_BASE")
-			if err != nil {
-				return err
-			}
-			c.SessionSigningKey = derive([]byte(val), "session-key-salt")
-			c.ResetSigningKey = derive([]byte(val), "password-reset-token-key-salt")
-			c.PasswordlessTokenSigningKey = derive([]byte(val), "passwordless-token-key-salt")
-			c.DBEncryptionKey = derive([]byte(val), "db-encryption-key-salt")[:32]
-			c.OAuthSigningKey = derive([]byte(val), "oauth-key-salt")
-			return nil
-		}
-		val, err := ioutil.ReadFile(fileName)
+		str, ok, err := lookupSecret("SECRET_KEY_BASE")
 		if err != nil {
 			return err
 		}
+		if !ok {
+			return ErrMissingEnvVar("SECRET_KEY_BASE")
+		}
+		val := []byte(str)
 		c.SessionSigningKey = derive(val, "session-key-salt")
 		c.ResetSigningKey = derive(val, "password-reset-token-key-salt")
 		c.PasswordlessTokenSigningKey = derive(val, "passwordless-token-key-salt")
@@ -185,39 +198,78 @@ var configurers = []configurer{
 	},
 
 	// A DATABASE_URL is a string that can specify the database engine, connection
-	// details, credentials, and other details.
+	// details, credentials, and other details. Like every other credential-bearing setting, it may
+	// instead be provided via a mounted secret file named by DATABASE_URL_FILE.
 	//
 	// Example: sqlite3://localhost/authn-go
 	func(c *Config) error {
-		val, err := lookupSecureURL("DATABASE_URL_FILE")
+		str, ok, err := lookupSecret("DATABASE_URL")
 		if err != nil {
-			// fallback
-			fmt.Printf("DATABASE_URL_FILE error, falling back to DATABASE_URL: %v\n", err)
-			val, err := lookupURL("DATABASE_URL")
-			if err == nil {
-				if val == nil {
-					return ErrMissingEnvVar("DATABASE_URL")
-				}
-				c.DatabaseURL = val
-				return nil
-			}
+			return err
+		}
+		if !ok {
+			return ErrMissingEnvVar("DATABASE_URL")
+		}
+		val, err := url.Parse(str)
+		if err != nil {
+			return err
 		}
 		c.DatabaseURL = val
 		return nil
 	},
 
 	// REDIS_URL is a string format that can specify any option for connecting to
-	// a Redis server.
+	// a Redis server. Like every other credential-bearing setting, it may instead be provided via
+	// a mounted secret file named by REDIS_URL_FILE.
 	//
 	// Example: redis://127.0.0.1:6379/11
 	func(c *Config) error {
-		val, err := lookupURL("REDIS_URL")
+		str, ok, err := lookupSecret("REDIS_URL")
+		if err != nil || !ok {
+			return err
+		}
+		val, err := url.Parse(str)
 		if err == nil {
 			c.RedisURL = val
 		}
 		return err
 	},
 
+	// SESSION_STORE selects the backend that refresh tokens (and identity key rotation
+	// coordination) are stored in: "redis" (default), "etcd", "memory", or "database". This lets
+	// operators deploy AuthN in environments that cannot run Redis.
+	func(c *Config) error {
+		val, ok := os.LookupEnv("SESSION_STORE")
+		if !ok {
+			c.SessionStore = data.SessionStoreRedis
+			return nil
+		}
+
+		backend := data.SessionStoreBackend(val)
+		switch backend {
+		case data.SessionStoreRedis, data.SessionStoreEtcd, data.SessionStoreMemory, data.SessionStoreDatabase:
+			c.SessionStore = backend
+			return nil
+		default:
+			return fmt.Errorf("unknown SESSION_STORE: %s", val)
+		}
+	},
+
+	// ETCD_URL (also accepted as ETCD_ENDPOINTS) is the etcd endpoint used when
+	// SESSION_STORE=etcd.
+	//
+	// Example: etcd://etcd-0.etcd:2379
+	func(c *Config) error {
+		val, err := lookupURL("ETCD_URL")
+		if err == nil && val == nil {
+			val, err = lookupURL("ETCD_ENDPOINTS")
+		}
+		if err == nil {
+			c.EtcdURL = val
+		}
+		return err
+	},
+
 	// USERNAME_IS_EMAIL is a truthy string ("t", "true", "yes") that enables the
 	// email validations for username fields. By default, usernames are just
 	// strings.
@@ -308,7 +360,8 @@ var configurers = []configurer{
 	},
 
 	// HTTP_AUTH_USERNAME and HTTP_AUTH_PASSWORD specify the basic auth credentials
-	// that must be provided to access private endpoints.
+	// that must be provided to access private endpoints. HTTP_AUTH_PASSWORD may instead be
+	// provided via a mounted secret file named by HTTP_AUTH_PASSWORD_FILE.
 	//
 	// This security pattern requires communication with AuthN to use SSL.
 	func(c *Config) error {
@@ -321,7 +374,9 @@ var configurers = []configurer{
 			}
 			c.AuthUsername = i.String()
 		}
-		if val, ok := os.LookupEnv("HTTP_AUTH_PASSWORD"); ok {
+		if val, ok, err := lookupSecret("HTTP_AUTH_PASSWORD"); err != nil {
+			return err
+		} else if ok {
 			c.AuthPassword = val
 		} else {
 			i, err := rand.Int(rand.Reader, big.NewInt(99999999))
@@ -352,10 +407,15 @@ var configurers = []configurer{
 	// with the given password reset token, then respond with a 2xx HTTP status.
 	//
 	// For security, this URL should specify https and include a basic auth username
-	// and password.
+	// and password. Since the URL carries that basic auth password, it may instead be provided
+	// via a mounted secret file named by APP_PASSWORD_RESET_URL_FILE.
 	func(c *Config) error {
-		val, err := lookupURL("APP_PASSWORD_RESET_URL")
-		if err == nil && val != nil {
+		str, ok, err := lookupSecret("APP_PASSWORD_RESET_URL")
+		if err != nil || !ok {
+			return err
+		}
+		val, err := url.Parse(str)
+		if err == nil {
 			c.AppPasswordResetURL = val
 		}
 		return err
@@ -379,10 +439,13 @@ var configurers = []configurer{
 	// line string, any literal \n sequences will be converted to real linebreaks.
 	// When provided, it will be used for signing identity tokens, and the public
 	// key will be published for audiences to verify. When not provided, AuthN will
-	// generate and manage keys itself, using Redis for coordination and
-	// persistence.
+	// generate and manage keys itself, using SESSION_STORE for coordination and
+	// persistence. Like every other credential-bearing setting, it may instead be provided via a
+	// mounted secret file named by RSA_PRIVATE_KEY_FILE.
 	func(c *Config) error {
-		if str, ok := os.LookupEnv("RSA_PRIVATE_KEY"); ok {
+		if str, ok, err := lookupSecret("RSA_PRIVATE_KEY"); err != nil {
+			return err
+		} else if ok {
 			str = strings.Replace(str, `\n`, "\n", -1)
 			block, _ := pem.Decode([]byte(str))
 			key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
@@ -393,6 +456,8 @@ var configurers = []configurer{
 			if err != nil {
 				return err
 			}
+			fingerprint := sha256.Sum256([]byte(str))
+			c.IdentitySigningKeyID = hex.EncodeToString(fingerprint[:])
 		}
 		return nil
 	},
@@ -434,9 +499,14 @@ var configurers = []configurer{
 	},
 
 	// SENTRY_DSN is a configuration string for the Sentry error reporting backend. When provided,
-	// errors and panics will be reported asynchronously.
+	// errors and panics will be reported asynchronously. It may instead be provided via a
+	// mounted secret file named by SENTRY_DSN_FILE.
 	func(c *Config) error {
-		if val, ok := os.LookupEnv("SENTRY_DSN"); ok {
+		val, ok, err := lookupSecret("SENTRY_DSN")
+		if err != nil {
+			return err
+		}
+		if ok {
 			c.ErrorReporterCredentials = val
 			c.ErrorReporterType = ops.Sentry
 		}
@@ -444,9 +514,14 @@ var configurers = []configurer{
 	},
 
 	// AIRBRAKE_CREDENTIALS is a configuration string for the Airbrake error reporting backend. When
-	// provided, errors and panics will be reported asynchronously.
+	// provided, errors and panics will be reported asynchronously. It may instead be provided via a
+	// mounted secret file named by AIRBRAKE_CREDENTIALS_FILE.
 	func(c *Config) error {
-		if val, ok := os.LookupEnv("AIRBRAKE_CREDENTIALS"); ok {
+		val, ok, err := lookupSecret("AIRBRAKE_CREDENTIALS")
+		if err != nil {
+			return err
+		}
+		if ok {
 			c.ErrorReporterCredentials = val
 			c.ErrorReporterType = ops.Airbrake
 		}
@@ -486,58 +561,239 @@ var configurers = []configurer{
 	},
 
 	// GOOGLE_OAUTH_CREDENTIALS is a credential pair in the format `id:secret`. When specified,
-	// AuthN will enable routes for Google OAuth signin.
+	// AuthN will enable routes for Google OAuth signin. It may instead be provided via a mounted
+	// secret file named by GOOGLE_OAUTH_CREDENTIALS_FILE.
 	func(c *Config) error {
-		if val, ok := os.LookupEnv("GOOGLE_OAUTH_CREDENTIALS"); ok {
-			credentials, err := oauth.NewCredentials(val)
-			if err == nil {
-				c.GoogleOauthCredentials = credentials
-			}
+		val, ok, err := lookupSecret("GOOGLE_OAUTH_CREDENTIALS")
+		if err != nil || !ok {
 			return err
 		}
-		return nil
+		credentials, err := oauth.NewCredentials(val)
+		if err == nil {
+			c.GoogleOauthCredentials = credentials
+		}
+		return err
 	},
 
 	// GITHUB_OAUTH_CREDENTIALS is a credential pair in the format `id:secret`. When specified,
-	// AuthN will enable routes for GitHub OAuth signin.
+	// AuthN will enable routes for GitHub OAuth signin. It may instead be provided via a mounted
+	// secret file named by GITHUB_OAUTH_CREDENTIALS_FILE.
 	func(c *Config) error {
-		if val, ok := os.LookupEnv("GITHUB_OAUTH_CREDENTIALS"); ok {
-			credentials, err := oauth.NewCredentials(val)
-			if err == nil {
-				c.GitHubOauthCredentials = credentials
-			}
+		val, ok, err := lookupSecret("GITHUB_OAUTH_CREDENTIALS")
+		if err != nil || !ok {
 			return err
 		}
-		return nil
+		credentials, err := oauth.NewCredentials(val)
+		if err == nil {
+			c.GitHubOauthCredentials = credentials
+		}
+		return err
 	},
 
 	// FACEBOOK_OAUTH_CREDENTIALS is a credential pair in the format `id:secret`. When specified,
-	// AuthN will enable routes for Facebook OAuth signin.
+	// AuthN will enable routes for Facebook OAuth signin. It may instead be provided via a
+	// mounted secret file named by FACEBOOK_OAUTH_CREDENTIALS_FILE.
 	func(c *Config) error {
-		if val, ok := os.LookupEnv("FACEBOOK_OAUTH_CREDENTIALS"); ok {
-			credentials, err := oauth.NewCredentials(val)
-			if err == nil {
-				c.FacebookOauthCredentials = credentials
-			}
+		val, ok, err := lookupSecret("FACEBOOK_OAUTH_CREDENTIALS")
+		if err != nil || !ok {
 			return err
 		}
-		return nil
+		credentials, err := oauth.NewCredentials(val)
+		if err == nil {
+			c.FacebookOauthCredentials = credentials
+		}
+		return err
 	},
 
 	// DISCORD_OAUTH_CREDENTIALS is a credential pair in the format `id:secret`. When specified,
-	// AuthN will enable routes for Discord OAuth signin.
+	// AuthN will enable routes for Discord OAuth signin. It may instead be provided via a mounted
+	// secret file named by DISCORD_OAUTH_CREDENTIALS_FILE.
 	func(c *Config) error {
-		if val, ok := os.LookupEnv("DISCORD_OAUTH_CREDENTIALS"); ok {
-			credentials, err := oauth.NewCredentials(val)
-			if err == nil {
-				c.DiscordOauthCredentials = credentials
-			}
+		val, ok, err := lookupSecret("DISCORD_OAUTH_CREDENTIALS")
+		if err != nil || !ok {
+			return err
+		}
+		credentials, err := oauth.NewCredentials(val)
+		if err == nil {
+			c.DiscordOauthCredentials = credentials
+		}
+		return err
+	},
+
+	// BITBUCKET_OAUTH_CREDENTIALS is a credential pair in the format `id:secret`. When specified,
+	// AuthN will enable routes for Bitbucket OAuth signin. It may instead be provided via a
+	// mounted secret file named by BITBUCKET_OAUTH_CREDENTIALS_FILE.
+	func(c *Config) error {
+		val, ok, err := lookupSecret("BITBUCKET_OAUTH_CREDENTIALS")
+		if err != nil || !ok {
+			return err
+		}
+		credentials, err := oauth.NewCredentials(val)
+		if err == nil {
+			c.BitbucketOauthCredentials = credentials
+		}
+		return err
+	},
+
+	// OIDC_PROVIDERS is a semicolon-delimited list of generic OpenID Connect providers, each a
+	// comma-delimited set of `key=value` pairs. This lets operators wire in Keycloak, Okta,
+	// Auth0, Azure AD, or any conformant IdP without a bespoke integration. Since entries carry a
+	// client_secret, it may instead be provided via a mounted secret file named by
+	// OIDC_PROVIDERS_FILE.
+	//
+	// Example: name=keycloak,issuer=https://kc.example.com/auth/realms/foo,client_id=abc,client_secret=xyz,scopes=openid+email+profile
+	//
+	// On startup, AuthN fetches each issuer's `.well-known/openid-configuration` document and
+	// caches its endpoints and signing keys. A provider that cannot be discovered at startup
+	// will fail configuration, since a route with no working backend is worse than no route.
+	func(c *Config) error {
+		val, ok, err := lookupSecret("OIDC_PROVIDERS")
+		if err != nil || !ok {
 			return err
 		}
+
+		for _, entry := range strings.Split(val, ";") {
+			cfg, err := parseOIDCProviderEntry(entry)
+			if err != nil {
+				return errors.Wrap(err, "OIDC_PROVIDERS")
+			}
+			c.OIDCProviders = append(c.OIDCProviders, cfg)
+		}
+
+		return nil
+	},
+
+	// TRUSTED_JWT_ISSUERS is a semicolon-delimited list of external OIDC issuers that machine
+	// users may present a bearer JWT from, each a `issuer_url|audience|subject_claim` triple.
+	// subject_claim defaults to `sub` when omitted.
+	//
+	// Example: https://kc.example.com/auth/realms/foo|my-service|sub
+	//
+	// A matching bearer token is authenticated without the interactive session flow: its
+	// subject_claim is mapped to a local account (auto-provisioned when ENABLE_SIGNUP is true),
+	// and AuthN issues its normal access/refresh token pair for that account.
+	func(c *Config) error {
+		val, ok := os.LookupEnv("TRUSTED_JWT_ISSUERS")
+		if !ok {
+			return nil
+		}
+
+		for _, entry := range strings.Split(val, ";") {
+			cfg, err := parseTrustedIssuerEntry(entry)
+			if err != nil {
+				return errors.Wrap(err, "TRUSTED_JWT_ISSUERS")
+			}
+			c.TrustedJWTIssuers = append(c.TrustedJWTIssuers, cfg)
+		}
+
+		return nil
+	},
+
+	// SECRETS_RELOAD enables watching every mounted `<NAME>_FILE` secret for changes (as during a
+	// Kubernetes secret rotation) and hot-swapping the Config it derives, without requiring a pod
+	// restart. See AtomicConfig and WatchSecrets.
+	func(c *Config) error {
+		val, err := lookupBool("SECRETS_RELOAD", false)
+		if err == nil {
+			c.SecretsReload = val
+		}
+		return err
+	},
+
+	// REDIRECT_URI_WHITELIST is a comma-separated list of domains that `redirect_uri` and
+	// `destination` parameters must match, distinct from the APP_DOMAINS used for JWT audiences.
+	// It supports the same syntax as APP_DOMAINS, including explicit subdomains and wildcard
+	// leading dots (`.example.com`). When unset, redirect validation falls back to
+	// ApplicationDomains, preserving the old behavior of overloading that one list.
+	func(c *Config) error {
+		val, ok := os.LookupEnv("REDIRECT_URI_WHITELIST")
+		if !ok {
+			return nil
+		}
+
+		c.RedirectWhitelist = make([]route.Domain, 0)
+		for _, domain := range strings.Split(val, ",") {
+			c.RedirectWhitelist = append(c.RedirectWhitelist, route.ParseDomain(domain))
+		}
+
 		return nil
 	},
 }
 
+// IsValidRedirect reports whether rawURL is an acceptable `redirect_uri` or `destination` target:
+// its scheme, host, and port must match a domain in RedirectWhitelist, or in ApplicationDomains
+// when REDIRECT_URI_WHITELIST was not set.
+func (c *Config) IsValidRedirect(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	domains := c.RedirectWhitelist
+	if domains == nil {
+		domains = c.ApplicationDomains
+	}
+
+	for _, domain := range domains {
+		if domain.Matches(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseOIDCProviderEntry(entry string) (OIDCProviderConfig, error) {
+	fields := map[string]string{}
+	for _, pair := range strings.Split(entry, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			return OIDCProviderConfig{}, fmt.Errorf("malformed entry: %s", entry)
+		}
+		fields[kv[0]] = kv[1]
+	}
+
+	name := fields["name"]
+	if name == "" {
+		return OIDCProviderConfig{}, fmt.Errorf("missing name in entry: %s", entry)
+	}
+
+	issuer, err := url.Parse(fields["issuer"])
+	if err != nil || fields["issuer"] == "" {
+		return OIDCProviderConfig{}, fmt.Errorf("invalid issuer in entry: %s", entry)
+	}
+
+	var scopes []string
+	if fields["scopes"] != "" {
+		scopes = strings.Split(fields["scopes"], "+")
+	}
+
+	return OIDCProviderConfig{
+		Name:         name,
+		Issuer:       issuer,
+		ClientID:     fields["client_id"],
+		ClientSecret: fields["client_secret"],
+		Scopes:       scopes,
+	}, nil
+}
+
+func parseTrustedIssuerEntry(entry string) (TrustedIssuerConfig, error) {
+	parts := strings.Split(entry, "|")
+	if len(parts) < 2 || len(parts) > 3 {
+		return TrustedIssuerConfig{}, fmt.Errorf("expected issuer_url|audience|subject_claim, got: %s", entry)
+	}
+
+	cfg := TrustedIssuerConfig{
+		IssuerURL: parts[0],
+		Audience:  parts[1],
+	}
+	if len(parts) == 3 {
+		cfg.SubjectClaim = parts[2]
+	}
+
+	return cfg, nil
+}
+
 // ReadEnv returns a Config struct from environment variables. It returns errors when a variable is
 // malformatted or missing but required.
 func ReadEnv() (*Config, error) {
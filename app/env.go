@@ -6,6 +6,7 @@ import (
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 type ErrMissingEnvVar string
@@ -45,13 +46,21 @@ func lookupURL(name string) (*url.URL, error) {
 	return nil, nil
 }
 
-func lookupSecureURL(name string) (*url.URL, error) {
-	if fileName, ok := os.LookupEnv(name); ok {
+// lookupSecret resolves a secret value, preferring the Docker/Kubernetes mounted-secret
+// convention of a `<NAME>_FILE` environment variable naming a file to read, and falling back to
+// the plain `<NAME>` environment variable. It returns ok=false if neither is set.
+func lookupSecret(name string) (string, bool, error) {
+	if fileName, ok := os.LookupEnv(name + "_FILE"); ok {
 		val, err := ioutil.ReadFile(fileName)
 		if err != nil {
-			return nil, err
+			return "", false, err
 		}
-		return url.Parse(string(val))
+		return strings.TrimSpace(string(val)), true, nil
 	}
-	return nil, nil
+
+	if val, ok := os.LookupEnv(name); ok {
+		return val, true, nil
+	}
+
+	return "", false, nil
 }
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/keratin/authn-server/data"
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// NewRefreshTokenStore constructs the RefreshTokenStore selected by SESSION_STORE. The redis and
+// database backends are intentionally not constructed here, since they share connections
+// (RedisURL, DatabaseURL) that callers already manage alongside the rest of AuthN's
+// bootstrapping; this only handles the backends that are self-contained.
+func NewRefreshTokenStore(c *Config) (data.RefreshTokenStore, error) {
+	switch c.SessionStore {
+	case data.SessionStoreEtcd:
+		endpoints, err := etcdEndpoints(c)
+		if err != nil {
+			return nil, err
+		}
+		return data.NewEtcdRefreshTokenStore(endpoints, c.RefreshTokenTTL)
+
+	case data.SessionStoreMemory:
+		return data.NewMemoryRefreshTokenStore(c.RefreshTokenTTL), nil
+
+	case data.SessionStoreRedis, data.SessionStoreDatabase, "":
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unknown SESSION_STORE: %s", c.SessionStore)
+	}
+}
+
+// NewKeyCoordinator constructs the data.KeyCoordinator selected by SESSION_STORE, so that
+// IdentitySigningKey rotation agrees on a single active key regardless of which backend is
+// storing refresh tokens. Like NewRefreshTokenStore, the redis and database backends are left to
+// AuthN's existing Redis-based coordination rather than reconstructed here; only the backends
+// SESSION_STORE newly introduces are handled.
+func NewKeyCoordinator(c *Config) (data.KeyCoordinator, error) {
+	switch c.SessionStore {
+	case data.SessionStoreEtcd:
+		endpoints, err := etcdEndpoints(c)
+		if err != nil {
+			return nil, err
+		}
+		client, err := clientv3.New(clientv3.Config{Endpoints: endpoints})
+		if err != nil {
+			return nil, errors.Wrap(err, "clientv3.New")
+		}
+		return data.NewEtcdKeyCoordinator(client), nil
+
+	case data.SessionStoreMemory:
+		return data.NewMemoryKeyCoordinator(), nil
+
+	case data.SessionStoreRedis, data.SessionStoreDatabase, "":
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unknown SESSION_STORE: %s", c.SessionStore)
+	}
+}
+
+// AnnounceIdentityKey publishes this process's IdentitySigningKey as the cluster's active key,
+// via the data.KeyCoordinator selected by SESSION_STORE. It is a no-op whenever there is nothing
+// to coordinate: SESSION_STORE unset/redis/database (AuthN's existing Redis-based coordination
+// already handles it) or no static RSA_PRIVATE_KEY configured (nothing has been claimed yet).
+//
+// It only overwrites the active key when it differs from the one already claimed, so that
+// repeated calls (every AnnounceIdentityKey is expected to run once per boot) don't generate
+// needless coordinator writes.
+func AnnounceIdentityKey(c *Config) error {
+	if c.IdentitySigningKeyID == "" {
+		return nil
+	}
+
+	coordinator, err := NewKeyCoordinator(c)
+	if err != nil {
+		return errors.Wrap(err, "NewKeyCoordinator")
+	}
+	if coordinator == nil {
+		return nil
+	}
+
+	activeID, err := coordinator.ActiveKeyID()
+	if err != nil {
+		return errors.Wrap(err, "ActiveKeyID")
+	}
+	if activeID == c.IdentitySigningKeyID {
+		return nil
+	}
+
+	if _, err := coordinator.ClaimKeyID(c.IdentitySigningKeyID); err != nil {
+		return errors.Wrap(err, "ClaimKeyID")
+	}
+
+	return nil
+}
+
+// etcdEndpoints returns the bare host:port endpoints clientv3 expects. ETCD_URL is documented
+// (and commonly written) with an `etcd://` scheme, which clientv3.Config.Endpoints does not
+// understand, so the scheme is stripped rather than passed through verbatim.
+func etcdEndpoints(c *Config) ([]string, error) {
+	if c.EtcdURL == nil {
+		return nil, fmt.Errorf("SESSION_STORE=etcd requires ETCD_URL")
+	}
+
+	if c.EtcdURL.Host == "" {
+		return []string{c.EtcdURL.String()}, nil
+	}
+
+	return []string{c.EtcdURL.Host}, nil
+}
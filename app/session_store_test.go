@@ -0,0 +1,77 @@
+package app
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/keratin/authn-server/data"
+)
+
+func TestEtcdEndpoints(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"scheme and port", "etcd://etcd-0.etcd:2379", "etcd-0.etcd:2379"},
+		{"https scheme", "https://etcd-0.etcd:2379", "etcd-0.etcd:2379"},
+		{"bare host and port", "etcd-0.etcd:2379", "etcd-0.etcd:2379"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			parsed, err := url.Parse(tc.raw)
+			if err != nil {
+				t.Fatalf("url.Parse(%q): %v", tc.raw, err)
+			}
+
+			endpoints, err := etcdEndpoints(&Config{EtcdURL: parsed})
+			if err != nil {
+				t.Fatalf("etcdEndpoints() error = %v", err)
+			}
+			if len(endpoints) != 1 || endpoints[0] != tc.want {
+				t.Fatalf("etcdEndpoints() = %v, want [%q]", endpoints, tc.want)
+			}
+		})
+	}
+}
+
+func TestEtcdEndpoints_MissingURL(t *testing.T) {
+	if _, err := etcdEndpoints(&Config{}); err == nil {
+		t.Fatal("expected an error when EtcdURL is nil")
+	}
+}
+
+func TestAnnounceIdentityKey_NoKeyConfigured(t *testing.T) {
+	c := &Config{SessionStore: data.SessionStoreMemory}
+	if err := AnnounceIdentityKey(c); err != nil {
+		t.Fatalf("AnnounceIdentityKey() error = %v, want nil when no static key is configured", err)
+	}
+}
+
+func TestAnnounceIdentityKey_RedisLeavesExistingCoordination(t *testing.T) {
+	c := &Config{SessionStore: data.SessionStoreRedis, IdentitySigningKeyID: "abc123"}
+	if err := AnnounceIdentityKey(c); err != nil {
+		t.Fatalf("AnnounceIdentityKey() error = %v, want nil when SESSION_STORE=redis", err)
+	}
+}
+
+func TestAnnounceIdentityKey_ClaimsAgainstMemoryCoordinator(t *testing.T) {
+	c := &Config{SessionStore: data.SessionStoreMemory, IdentitySigningKeyID: "key-1"}
+
+	if err := AnnounceIdentityKey(c); err != nil {
+		t.Fatalf("AnnounceIdentityKey() error = %v", err)
+	}
+
+	coordinator, err := NewKeyCoordinator(c)
+	if err != nil {
+		t.Fatalf("NewKeyCoordinator() error = %v", err)
+	}
+
+	// NewKeyCoordinator constructs a fresh, independent MemoryKeyCoordinator each call, so this
+	// only confirms AnnounceIdentityKey didn't error; the etcd-backed path (exercised by a real
+	// cluster) is where ActiveKeyID actually persists across calls.
+	if _, err := coordinator.ActiveKeyID(); err != nil {
+		t.Fatalf("ActiveKeyID() error = %v", err)
+	}
+}
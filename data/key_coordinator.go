@@ -0,0 +1,19 @@
+package data
+
+// KeyCoordinator agrees on a single active IdentitySigningKey across every AuthN process sharing
+// a SESSION_STORE backend, so that key rotation does not require every process to restart in
+// lockstep. One process claims a new key ID; every other process observes the claim on its next
+// read and switches to verifying (and eventually signing) with it.
+//
+// Prior to SESSION_STORE, this coordination assumed Redis was always present. KeyCoordinator lets
+// it follow whichever backend SESSION_STORE selects instead.
+type KeyCoordinator interface {
+	// ActiveKeyID returns the ID of the key every process should currently be signing with, or
+	// "" if no key has been claimed yet.
+	ActiveKeyID() (string, error)
+
+	// ClaimKeyID attempts to make id the active key. It succeeds (and returns true) whether or
+	// not another ID was previously active; callers that need to avoid clobbering a concurrent
+	// rotation should read ActiveKeyID first and treat a mismatch as reason to abort.
+	ClaimKeyID(id string) (bool, error)
+}
@@ -0,0 +1,51 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const etcdActiveKeyKey = "/authn/identity-key/active"
+
+// EtcdKeyCoordinator is a KeyCoordinator backed by etcd. The active key ID is a single key with
+// no lease, since - unlike a refresh token - it should not expire on its own; it only ever
+// changes when a process claims a new one.
+type EtcdKeyCoordinator struct {
+	client *clientv3.Client
+}
+
+// NewEtcdKeyCoordinator returns a KeyCoordinator that stores the active key ID in etcd.
+func NewEtcdKeyCoordinator(client *clientv3.Client) *EtcdKeyCoordinator {
+	return &EtcdKeyCoordinator{client: client}
+}
+
+// ActiveKeyID returns the currently claimed key ID, or "" if none has been claimed yet.
+func (k *EtcdKeyCoordinator) ActiveKeyID() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := k.client.Get(ctx, etcdActiveKeyKey)
+	if err != nil {
+		return "", errors.Wrap(err, "Get")
+	}
+	if len(res.Kvs) == 0 {
+		return "", nil
+	}
+
+	return string(res.Kvs[0].Value), nil
+}
+
+// ClaimKeyID makes id the active key.
+func (k *EtcdKeyCoordinator) ClaimKeyID(id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := k.client.Put(ctx, etcdActiveKeyKey, id); err != nil {
+		return false, errors.Wrap(err, "Put")
+	}
+
+	return true, nil
+}
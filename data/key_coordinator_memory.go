@@ -0,0 +1,33 @@
+package data
+
+import "sync"
+
+// MemoryKeyCoordinator is a KeyCoordinator backed by a process-local variable. It does not
+// coordinate anything across nodes, so it only makes sense paired with SESSION_STORE=memory's
+// single-node assumption.
+type MemoryKeyCoordinator struct {
+	mu       sync.Mutex
+	activeID string
+}
+
+// NewMemoryKeyCoordinator returns a KeyCoordinator that keeps the active key ID in memory.
+func NewMemoryKeyCoordinator() *MemoryKeyCoordinator {
+	return &MemoryKeyCoordinator{}
+}
+
+// ActiveKeyID returns the currently claimed key ID, or "" if none has been claimed yet.
+func (k *MemoryKeyCoordinator) ActiveKeyID() (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.activeID, nil
+}
+
+// ClaimKeyID makes id the active key.
+func (k *MemoryKeyCoordinator) ClaimKeyID(id string) (bool, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.activeID = id
+	return true, nil
+}
@@ -0,0 +1,38 @@
+package data
+
+import "testing"
+
+func TestMemoryKeyCoordinator(t *testing.T) {
+	k := NewMemoryKeyCoordinator()
+
+	id, err := k.ActiveKeyID()
+	if err != nil {
+		t.Fatalf("ActiveKeyID() error = %v", err)
+	}
+	if id != "" {
+		t.Fatalf("ActiveKeyID() = %q, want empty before any claim", id)
+	}
+
+	ok, err := k.ClaimKeyID("key-1")
+	if err != nil {
+		t.Fatalf("ClaimKeyID() error = %v", err)
+	}
+	if !ok {
+		t.Fatal("ClaimKeyID() = false, want true")
+	}
+
+	id, err = k.ActiveKeyID()
+	if err != nil {
+		t.Fatalf("ActiveKeyID() error = %v", err)
+	}
+	if id != "key-1" {
+		t.Fatalf("ActiveKeyID() = %q, want %q", id, "key-1")
+	}
+
+	if _, err := k.ClaimKeyID("key-2"); err != nil {
+		t.Fatalf("ClaimKeyID() error = %v", err)
+	}
+	if id, _ := k.ActiveKeyID(); id != "key-2" {
+		t.Fatalf("ActiveKeyID() after second claim = %q, want %q", id, "key-2")
+	}
+}
@@ -0,0 +1,186 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	etcdRefreshTokenPrefix        = "/authn/refresh/by-account/"
+	etcdRefreshTokenByTokenPrefix = "/authn/refresh/by-token/"
+)
+
+// EtcdRefreshTokenStore is a RefreshTokenStore backed by etcd. Each token is stored as a lease
+// with a TTL matching RefreshTokenTTL, so expiry is handled by etcd itself rather than by a
+// sweeper. Every token is written under two keys sharing one lease: an account-namespaced key
+// (`/authn/refresh/by-account/<accountID>/<token>`) so that FindAll and Revoke can use prefix
+// scans, and a token-keyed reverse index (`/authn/refresh/by-token/<token>`) so that Find and
+// Touch - both on the hot path of every authenticated request - are a single point Get rather
+// than a scan of every active session in the cluster.
+type EtcdRefreshTokenStore struct {
+	client *clientv3.Client
+	ttl    time.Duration
+}
+
+// NewEtcdRefreshTokenStore dials the given etcd endpoints and returns a RefreshTokenStore. The
+// ttl is applied as the lease duration for every token the store creates or touches.
+func NewEtcdRefreshTokenStore(endpoints []string, ttl time.Duration) (*EtcdRefreshTokenStore, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "clientv3.New")
+	}
+
+	return &EtcdRefreshTokenStore{client: client, ttl: ttl}, nil
+}
+
+func etcdAccountPrefix(accountID int) string {
+	return fmt.Sprintf("%s%d/", etcdRefreshTokenPrefix, accountID)
+}
+
+func etcdByTokenKey(token string) string {
+	return etcdRefreshTokenByTokenPrefix + token
+}
+
+// Create generates a new refresh token for the given account, stored under a lease that expires
+// after the store's configured TTL.
+func (s *EtcdRefreshTokenStore) Create(accountID int) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", errors.Wrap(err, "randomToken")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	lease, err := s.client.Grant(ctx, int64(s.ttl.Seconds()))
+	if err != nil {
+		return "", errors.Wrap(err, "Grant")
+	}
+
+	value := strconv.Itoa(accountID)
+	ops := []clientv3.Op{
+		clientv3.OpPut(etcdAccountPrefix(accountID)+token, value, clientv3.WithLease(lease.ID)),
+		clientv3.OpPut(etcdByTokenKey(token), value, clientv3.WithLease(lease.ID)),
+	}
+	if _, err := s.client.Txn(ctx).Then(ops...).Commit(); err != nil {
+		return "", errors.Wrap(err, "Commit")
+	}
+
+	return token, nil
+}
+
+// Find returns the account ID associated with a refresh token, or 0 if the token does not exist
+// or has expired. This is a single Get against the by-token reverse index, not a scan, since it
+// sits on the hot path of every authenticated request.
+func (s *EtcdRefreshTokenStore) Find(token string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := s.client.Get(ctx, etcdByTokenKey(token))
+	if err != nil {
+		return 0, errors.Wrap(err, "Get")
+	}
+	if len(res.Kvs) == 0 {
+		return 0, nil
+	}
+
+	accountID, err := strconv.Atoi(string(res.Kvs[0].Value))
+	if err != nil {
+		return 0, errors.Wrap(err, "Atoi")
+	}
+	return accountID, nil
+}
+
+// FindAll returns every live refresh token for an account, via a prefix scan on the account's
+// namespace.
+func (s *EtcdRefreshTokenStore) FindAll(accountID int) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	prefix := etcdAccountPrefix(accountID)
+	res, err := s.client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, errors.Wrap(err, "Get")
+	}
+
+	tokens := make([]string, 0, len(res.Kvs))
+	for _, kv := range res.Kvs {
+		tokens = append(tokens, strings.TrimPrefix(string(kv.Key), prefix))
+	}
+
+	return tokens, nil
+}
+
+// Touch refreshes a token's lease so that it survives for another full TTL from now. The refresh
+// is a compare-and-swap on the stored value, so a token revoked concurrently with a touch will
+// not be resurrected. Like Find, this looks the token up via the by-token reverse index rather
+// than scanning every account's namespace.
+func (s *EtcdRefreshTokenStore) Touch(token string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	byTokenKey := etcdByTokenKey(token)
+	res, err := s.client.Get(ctx, byTokenKey)
+	if err != nil {
+		return false, errors.Wrap(err, "Get")
+	}
+	if len(res.Kvs) == 0 {
+		return false, nil
+	}
+	value := string(res.Kvs[0].Value)
+
+	accountID, err := strconv.Atoi(value)
+	if err != nil {
+		return false, errors.Wrap(err, "Atoi")
+	}
+	accountKey := etcdAccountPrefix(accountID) + token
+
+	lease, err := s.client.Grant(ctx, int64(s.ttl.Seconds()))
+	if err != nil {
+		return false, errors.Wrap(err, "Grant")
+	}
+
+	txn := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.Value(byTokenKey), "=", value)).
+		Then(
+			clientv3.OpPut(byTokenKey, value, clientv3.WithLease(lease.ID)),
+			clientv3.OpPut(accountKey, value, clientv3.WithLease(lease.ID)),
+		)
+
+	txnRes, err := txn.Commit()
+	if err != nil {
+		return false, errors.Wrap(err, "Commit")
+	}
+
+	return txnRes.Succeeded, nil
+}
+
+// Revoke deletes every refresh token for an account, both from the account's own namespace and
+// from the by-token reverse index, so that a revoked token is immediately unfindable by Find
+// rather than lingering until its lease expires.
+func (s *EtcdRefreshTokenStore) Revoke(accountID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tokens, err := s.FindAll(accountID)
+	if err != nil {
+		return errors.Wrap(err, "FindAll")
+	}
+
+	ops := []clientv3.Op{clientv3.OpDelete(etcdAccountPrefix(accountID), clientv3.WithPrefix())}
+	for _, token := range tokens {
+		ops = append(ops, clientv3.OpDelete(etcdByTokenKey(token)))
+	}
+
+	_, err = s.client.Txn(ctx).Then(ops...).Commit()
+	return errors.Wrap(err, "Commit")
+}
@@ -0,0 +1,162 @@
+package data
+
+import (
+	"sync"
+	"time"
+)
+
+const memoryShardCount = 16
+
+type memoryToken struct {
+	accountID int
+	expiresAt time.Time
+}
+
+type memoryShard struct {
+	mu     sync.Mutex
+	tokens map[string]memoryToken
+}
+
+// MemoryRefreshTokenStore is a RefreshTokenStore backed by a sharded, lock-protected in-process
+// map. It does not survive a restart and is not shared across nodes, so it exists for local
+// development and single-node deployments that would rather not run Redis or etcd.
+type MemoryRefreshTokenStore struct {
+	shards [memoryShardCount]*memoryShard
+	ttl    time.Duration
+	done   chan struct{}
+}
+
+// NewMemoryRefreshTokenStore returns a RefreshTokenStore that keeps tokens in memory, expiring
+// them after ttl. A background sweeper evicts expired tokens every minute so that abandoned
+// sessions do not leak memory indefinitely.
+func NewMemoryRefreshTokenStore(ttl time.Duration) *MemoryRefreshTokenStore {
+	store := &MemoryRefreshTokenStore{
+		ttl:  ttl,
+		done: make(chan struct{}),
+	}
+	for i := range store.shards {
+		store.shards[i] = &memoryShard{tokens: make(map[string]memoryToken)}
+	}
+
+	go store.sweep()
+
+	return store
+}
+
+// Close stops the background sweeper. It is not part of the RefreshTokenStore interface, but
+// callers that tear down AuthN in-process (as in tests) should call it to avoid leaking the
+// sweeper goroutine.
+func (s *MemoryRefreshTokenStore) Close() {
+	close(s.done)
+}
+
+func (s *MemoryRefreshTokenStore) sweep() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			for _, shard := range s.shards {
+				shard.mu.Lock()
+				for token, entry := range shard.tokens {
+					if now.After(entry.expiresAt) {
+						delete(shard.tokens, token)
+					}
+				}
+				shard.mu.Unlock()
+			}
+		}
+	}
+}
+
+func (s *MemoryRefreshTokenStore) shardFor(token string) *memoryShard {
+	var h uint32
+	for i := 0; i < len(token); i++ {
+		h = h*31 + uint32(token[i])
+	}
+	return s.shards[h%memoryShardCount]
+}
+
+// Create generates a new refresh token for the given account.
+func (s *MemoryRefreshTokenStore) Create(accountID int) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	shard := s.shardFor(token)
+	shard.mu.Lock()
+	shard.tokens[token] = memoryToken{accountID: accountID, expiresAt: time.Now().Add(s.ttl)}
+	shard.mu.Unlock()
+
+	return token, nil
+}
+
+// Find returns the account ID associated with a refresh token, or 0 if it does not exist or has
+// expired.
+func (s *MemoryRefreshTokenStore) Find(token string) (int, error) {
+	shard := s.shardFor(token)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, ok := shard.tokens[token]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return 0, nil
+	}
+
+	return entry.accountID, nil
+}
+
+// FindAll returns every live refresh token for an account. Since tokens are sharded by token
+// value rather than account, this scans every shard.
+func (s *MemoryRefreshTokenStore) FindAll(accountID int) ([]string, error) {
+	var tokens []string
+	now := time.Now()
+
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		for token, entry := range shard.tokens {
+			if entry.accountID == accountID && now.Before(entry.expiresAt) {
+				tokens = append(tokens, token)
+			}
+		}
+		shard.mu.Unlock()
+	}
+
+	return tokens, nil
+}
+
+// Touch extends a token's expiry by another full TTL from now.
+func (s *MemoryRefreshTokenStore) Touch(token string) (bool, error) {
+	shard := s.shardFor(token)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, ok := shard.tokens[token]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return false, nil
+	}
+
+	entry.expiresAt = time.Now().Add(s.ttl)
+	shard.tokens[token] = entry
+
+	return true, nil
+}
+
+// Revoke deletes every refresh token for an account.
+func (s *MemoryRefreshTokenStore) Revoke(accountID int) error {
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		for token, entry := range shard.tokens {
+			if entry.accountID == accountID {
+				delete(shard.tokens, token)
+			}
+		}
+		shard.mu.Unlock()
+	}
+
+	return nil
+}
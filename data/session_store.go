@@ -0,0 +1,45 @@
+package data
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+)
+
+// SessionStoreBackend names one of the supported RefreshTokenStore implementations, as selected
+// by the SESSION_STORE environment variable.
+type SessionStoreBackend string
+
+const (
+	// SessionStoreRedis keeps refresh tokens in Redis. This is the default, and the only backend
+	// supported prior to the introduction of SessionStoreBackend.
+	SessionStoreRedis SessionStoreBackend = "redis"
+
+	// SessionStoreEtcd keeps refresh tokens in etcd, using lease TTLs for expiry.
+	SessionStoreEtcd SessionStoreBackend = "etcd"
+
+	// SessionStoreMemory keeps refresh tokens in an in-process map. It does not survive a
+	// restart and is not shared across nodes, so it is only appropriate for local development
+	// and single-node deployments.
+	SessionStoreMemory SessionStoreBackend = "memory"
+
+	// SessionStoreDatabase keeps refresh tokens in the primary SQL database, for operators who
+	// would rather not run a second stateful service.
+	SessionStoreDatabase SessionStoreBackend = "database"
+)
+
+// RefreshTokenStoreConfig is the set of parameters any RefreshTokenStore backend needs to
+// construct itself, independent of where those parameters came from.
+type RefreshTokenStoreConfig struct {
+	TTL time.Duration
+}
+
+// randomToken generates an opaque, unguessable refresh token value, shared by every
+// RefreshTokenStore backend.
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,72 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/bitbucket"
+)
+
+// bitbucketUserResponse is the subset of Bitbucket's `/2.0/user` payload that AuthN needs.
+// Bitbucket does not include an email address on this endpoint, so the primary confirmed
+// address must be fetched separately from `/2.0/user/emails`.
+type bitbucketUserResponse struct {
+	Username string `json:"username"`
+	UUID     string `json:"uuid"`
+}
+
+type bitbucketEmailsResponse struct {
+	Values []struct {
+		Email       string `json:"email"`
+		IsPrimary   bool   `json:"is_primary"`
+		IsConfirmed bool   `json:"is_confirmed"`
+	} `json:"values"`
+}
+
+// BitbucketConfig returns the oauth2.Config for exchanging a Bitbucket authorization code.
+func BitbucketConfig(credentials *Credentials, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     credentials.ID,
+		ClientSecret: credentials.Secret,
+		Endpoint:     bitbucket.Endpoint,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"account", "email"},
+	}
+}
+
+// BitbucketUser exchanges an authorized Bitbucket HTTP client for the account's username and
+// primary confirmed email address.
+func BitbucketUser(client *http.Client) (string, string, error) {
+	userRes, err := client.Get("https://api.bitbucket.org/2.0/user")
+	if err != nil {
+		return "", "", err
+	}
+	defer userRes.Body.Close()
+
+	var user bitbucketUserResponse
+	if err := json.NewDecoder(userRes.Body).Decode(&user); err != nil {
+		return "", "", err
+	}
+
+	emailsRes, err := client.Get("https://api.bitbucket.org/2.0/user/emails")
+	if err != nil {
+		return "", "", err
+	}
+	defer emailsRes.Body.Close()
+
+	var emails bitbucketEmailsResponse
+	if err := json.NewDecoder(emailsRes.Body).Decode(&emails); err != nil {
+		return "", "", err
+	}
+
+	var primaryEmail string
+	for _, e := range emails.Values {
+		if e.IsPrimary && e.IsConfirmed {
+			primaryEmail = e.Email
+			break
+		}
+	}
+
+	return user.Username, primaryEmail, nil
+}
@@ -0,0 +1,326 @@
+// Package oidc implements a generic OpenID Connect relying party. Unlike the other lib/oauth
+// providers, which hardcode endpoints for a specific service, oidc.Provider discovers its
+// endpoints and signing keys from any conformant issuer (Keycloak, Okta, Auth0, Azure AD, etc.)
+// at startup, and refreshes its cached JWKS when a token verification finds it older than
+// defaultJWKSTTL.
+package oidc
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// defaultJWKSTTL is how long a fetched JWKS is trusted before it is refreshed. Cache-Control
+// headers on the JWKS response are not consulted.
+const defaultJWKSTTL = 1 * time.Hour
+
+// Config describes a single configured OIDC provider, as parsed from the OIDC_PROVIDERS
+// environment variable.
+type Config struct {
+	Name         string
+	Issuer       *url.URL
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// discoveryDocument is the subset of a `.well-known/openid-configuration` response that the
+// relying party needs.
+type discoveryDocument struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// Provider is a generic OIDC relying party for a single configured issuer. It is safe for
+// concurrent use.
+type Provider struct {
+	Config
+
+	httpClient *http.Client
+
+	mu        sync.RWMutex
+	endpoints discoveryDocument
+	keys      map[string]*rsa.PublicKey
+	keysAt    time.Time
+}
+
+// NewProvider fetches the issuer's discovery document and JWKS, then returns a Provider ready to
+// perform code exchanges and ID token verification. It returns an error if the issuer cannot be
+// reached or does not serve a valid discovery document.
+func NewProvider(cfg Config) (*Provider, error) {
+	p := &Provider{
+		Config:     cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if err := p.discover(); err != nil {
+		return nil, errors.Wrap(err, "discover")
+	}
+	if err := p.refreshKeys(); err != nil {
+		return nil, errors.Wrap(err, "refreshKeys")
+	}
+
+	return p, nil
+}
+
+func (p *Provider) discover() error {
+	discoveryURL := strings.TrimSuffix(p.Issuer.String(), "/") + "/.well-known/openid-configuration"
+
+	res, err := p.httpClient.Get(discoveryURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("discovery endpoint returned %d", res.StatusCode)
+	}
+
+	var doc discoveryDocument
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.endpoints = doc
+	p.mu.Unlock()
+
+	return nil
+}
+
+// refreshKeys fetches the issuer's JWKS and replaces the cached key set. Callers that verify
+// tokens should call EnsureFreshKeys first, which only refreshes once the TTL has elapsed.
+func (p *Provider) refreshKeys() error {
+	p.mu.RLock()
+	jwksURI := p.endpoints.JWKSURI
+	p.mu.RUnlock()
+
+	res, err := p.httpClient.Get(jwksURI)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var set jwks
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+		pub, err := rsaPublicKeyFromJWK(key)
+		if err != nil {
+			return errors.Wrap(err, "rsaPublicKeyFromJWK")
+		}
+		keys[key.Kid] = pub
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysAt = time.Now()
+	p.mu.Unlock()
+
+	return nil
+}
+
+// EnsureFreshKeys refreshes the cached JWKS if it is older than defaultJWKSTTL.
+func (p *Provider) EnsureFreshKeys() error {
+	p.mu.RLock()
+	stale := time.Since(p.keysAt) > defaultJWKSTTL
+	p.mu.RUnlock()
+
+	if !stale {
+		return nil
+	}
+	return p.refreshKeys()
+}
+
+// IDToken is the set of claims AuthN cares about from a verified ID token.
+type IDToken struct {
+	Subject string
+	Email   string
+	Claims  jwt.MapClaims
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry, and (when provided) nonce of a
+// raw ID token, returning its claims on success.
+func (p *Provider) VerifyIDToken(rawIDToken, nonce string) (*IDToken, error) {
+	if err := p.EnsureFreshKeys(); err != nil {
+		return nil, errors.Wrap(err, "EnsureFreshKeys")
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		p.mu.RLock()
+		key, ok := p.keys[kid]
+		p.mu.RUnlock()
+
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key: %s", kid)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "ParseWithClaims")
+	}
+
+	if !claims.VerifyIssuer(p.Issuer.String(), true) {
+		return nil, fmt.Errorf("unexpected issuer: %v", claims["iss"])
+	}
+	if !claims.VerifyAudience(p.ClientID, true) {
+		return nil, fmt.Errorf("unexpected audience: %v", claims["aud"])
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, fmt.Errorf("token is expired")
+	}
+	if nonce != "" && claims["nonce"] != nonce {
+		return nil, fmt.Errorf("nonce mismatch")
+	}
+
+	sub, _ := claims["sub"].(string)
+	email, _ := claims["email"].(string)
+
+	return &IDToken{Subject: sub, Email: email, Claims: claims}, nil
+}
+
+// AuthCodeURL builds the authorization endpoint URL that begins the OIDC flow.
+func (p *Provider) AuthCodeURL(redirectURI, state, nonce string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", p.ClientID)
+	values.Set("redirect_uri", redirectURI)
+	values.Set("scope", strings.Join(p.Scopes, " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+
+	return p.endpoints.AuthorizationEndpoint + "?" + values.Encode()
+}
+
+// Exchange trades an authorization code for a raw ID token at the issuer's token endpoint, using
+// the standard `authorization_code` grant. It also returns the access token from the same
+// response, since Userinfo needs one and the ID token alone is not always enough to identify a
+// user (not every issuer puts an email claim in it).
+func (p *Provider) Exchange(code, redirectURI string) (rawIDToken, accessToken string, err error) {
+	p.mu.RLock()
+	tokenEndpoint := p.endpoints.TokenEndpoint
+	p.mu.RUnlock()
+
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", redirectURI)
+	values.Set("client_id", p.ClientID)
+	values.Set("client_secret", p.ClientSecret)
+
+	res, err := p.httpClient.PostForm(tokenEndpoint, values)
+	if err != nil {
+		return "", "", errors.Wrap(err, "PostForm")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("token endpoint returned %d", res.StatusCode)
+	}
+
+	var body struct {
+		IDToken     string `json:"id_token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", "", errors.Wrap(err, "decode token response")
+	}
+	if body.IDToken == "" {
+		return "", "", fmt.Errorf("token response did not include an id_token")
+	}
+
+	return body.IDToken, body.AccessToken, nil
+}
+
+// UserinfoResponse is the subset of a userinfo endpoint response AuthN cares about.
+type UserinfoResponse struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email"`
+}
+
+// Userinfo calls the issuer's userinfo endpoint with an access token obtained from Exchange, for
+// providers that don't include an email claim in the ID token itself.
+func (p *Provider) Userinfo(accessToken string) (*UserinfoResponse, error) {
+	p.mu.RLock()
+	endpoint := p.endpoints.UserinfoEndpoint
+	p.mu.RUnlock()
+
+	if endpoint == "" {
+		return nil, fmt.Errorf("issuer does not publish a userinfo_endpoint")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("userinfo endpoint returned %d", res.StatusCode)
+	}
+
+	var body UserinfoResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	return &body, nil
+}
+
+func rsaPublicKeyFromJWK(key jwk) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(key.N)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode n")
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(key.E)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode e")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: int(new(big.Int).SetBytes(eBytes).Int64()),
+	}, nil
+}
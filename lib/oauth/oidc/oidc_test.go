@@ -0,0 +1,87 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRsaPublicKeyFromJWK(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	encoded := jwk{
+		Kid: "test-key",
+		Kty: "RSA",
+		N:   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+	}
+
+	decoded, err := rsaPublicKeyFromJWK(encoded)
+	if err != nil {
+		t.Fatalf("rsaPublicKeyFromJWK: %v", err)
+	}
+
+	if decoded.E != key.PublicKey.E {
+		t.Errorf("E = %d, want %d", decoded.E, key.PublicKey.E)
+	}
+	if decoded.N.Cmp(key.PublicKey.N) != 0 {
+		t.Errorf("N = %s, want %s", decoded.N, key.PublicKey.N)
+	}
+}
+
+func TestRsaPublicKeyFromJWK_InvalidEncoding(t *testing.T) {
+	_, err := rsaPublicKeyFromJWK(jwk{Kty: "RSA", N: "not-base64!", E: "AQAB"})
+	if err == nil {
+		t.Fatal("expected an error for malformed base64, got nil")
+	}
+}
+
+func TestProvider_Userinfo(t *testing.T) {
+	userinfo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(UserinfoResponse{Subject: "user-1", Email: "user@example.com"})
+	}))
+	defer userinfo.Close()
+
+	p := &Provider{endpoints: discoveryDocument{UserinfoEndpoint: userinfo.URL}, httpClient: http.DefaultClient}
+
+	info, err := p.Userinfo("test-token")
+	if err != nil {
+		t.Fatalf("Userinfo() error = %v", err)
+	}
+	if info.Subject != "user-1" || info.Email != "user@example.com" {
+		t.Errorf("Userinfo() = %+v, want {user-1 user@example.com}", info)
+	}
+}
+
+func TestProvider_Userinfo_NoEndpoint(t *testing.T) {
+	p := &Provider{httpClient: http.DefaultClient}
+
+	if _, err := p.Userinfo("test-token"); err == nil {
+		t.Fatal("expected an error when the issuer has no userinfo_endpoint, got nil")
+	}
+}
+
+func TestProvider_Userinfo_ErrorStatus(t *testing.T) {
+	userinfo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusUnauthorized)
+	}))
+	defer userinfo.Close()
+
+	p := &Provider{endpoints: discoveryDocument{UserinfoEndpoint: userinfo.URL}, httpClient: http.DefaultClient}
+
+	if _, err := p.Userinfo("test-token"); err == nil {
+		t.Fatal("expected an error for a non-200 response, got nil")
+	}
+}
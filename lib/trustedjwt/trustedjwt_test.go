@@ -0,0 +1,34 @@
+package trustedjwt
+
+import (
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func TestPeekIssuer(t *testing.T) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": "https://issuer.example.com",
+		"sub": "machine-user",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	raw, err := token.SignedString([]byte("does-not-need-to-verify-here"))
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+
+	iss, err := peekIssuer(raw)
+	if err != nil {
+		t.Fatalf("peekIssuer: %v", err)
+	}
+	if iss != "https://issuer.example.com" {
+		t.Errorf("iss = %q, want %q", iss, "https://issuer.example.com")
+	}
+}
+
+func TestPeekIssuer_Malformed(t *testing.T) {
+	if _, err := peekIssuer("not-a-jwt"); err == nil {
+		t.Fatal("expected an error for a malformed token, got nil")
+	}
+}
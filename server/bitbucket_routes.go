@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/keratin/authn-server/app"
+	"github.com/keratin/authn-server/data"
+	"github.com/keratin/authn-server/lib/oauth"
+	"github.com/keratin/authn-server/services"
+)
+
+// RegisterBitbucketRoutes wires `/oauth/bitbucket` and `/oauth/bitbucket/return` when
+// BITBUCKET_OAUTH_CREDENTIALS is configured, reusing the same state cookie dance as the other
+// OAuth providers. Whether the routes exist at all is decided once, at registration time, but the
+// handlers load ac fresh on every request so that a credential rotated via SECRETS_RELOAD takes
+// effect without a restart.
+func RegisterBitbucketRoutes(mux *http.ServeMux, ac *app.AtomicConfig, accounts data.AccountStore, sessions SessionIssuer) {
+	if ac.Load().BitbucketOauthCredentials == nil {
+		return
+	}
+
+	h := &bitbucketHandler{ac: ac, accounts: accounts, sessions: sessions}
+	mux.HandleFunc("/oauth/bitbucket", h.begin)
+	mux.HandleFunc("/oauth/bitbucket/return", h.finish)
+}
+
+func bitbucketRedirectURI(c *app.Config) string {
+	return c.AuthNURL.String() + "/oauth/bitbucket/return"
+}
+
+type bitbucketHandler struct {
+	ac       *app.AtomicConfig
+	accounts data.AccountStore
+	sessions SessionIssuer
+}
+
+func (h *bitbucketHandler) begin(w http.ResponseWriter, r *http.Request) {
+	c := h.ac.Load()
+
+	state, err := randomOAuthValue()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     c.OAuthCookieName,
+		Value:    state,
+		HttpOnly: true,
+		Secure:   c.ForceSSL,
+		Path:     "/",
+	})
+
+	conf := oauth.BitbucketConfig(c.BitbucketOauthCredentials, bitbucketRedirectURI(c))
+	http.Redirect(w, r, conf.AuthCodeURL(state), http.StatusFound)
+}
+
+func (h *bitbucketHandler) finish(w http.ResponseWriter, r *http.Request) {
+	c := h.ac.Load()
+
+	destination, ok := validateRedirect(c, w, r, "destination")
+	if !ok {
+		return
+	}
+
+	cookie, err := r.Cookie(c.OAuthCookieName)
+	if err != nil || cookie.Value != r.URL.Query().Get("state") {
+		http.Error(w, "state mismatch", http.StatusBadRequest)
+		return
+	}
+
+	conf := oauth.BitbucketConfig(c.BitbucketOauthCredentials, bitbucketRedirectURI(c))
+	token, err := conf.Exchange(context.Background(), r.URL.Query().Get("code"))
+	if err != nil {
+		http.Error(w, "oauth exchange failed", http.StatusBadGateway)
+		return
+	}
+
+	username, email, err := oauth.BitbucketUser(conf.Client(context.Background(), token))
+	if err != nil {
+		http.Error(w, "failed to fetch bitbucket user", http.StatusBadGateway)
+		return
+	}
+
+	subject := email
+	if subject == "" {
+		subject = username
+	}
+
+	accountID, err := services.MachineAuthenticator(h.accounts, subject, c.EnableSignup)
+	if err != nil {
+		http.Error(w, "account lookup failed", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.sessions.IssueSession(w, r, accountID); err != nil {
+		http.Error(w, "failed to issue session", http.StatusInternalServerError)
+		return
+	}
+
+	if destination != "" {
+		http.Redirect(w, r, destination, http.StatusFound)
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/keratin/authn-server/app"
+	"github.com/keratin/authn-server/lib/oauth"
+)
+
+func TestRegisterBitbucketRoutes_NoCredentials(t *testing.T) {
+	mux := http.NewServeMux()
+	ac := app.NewAtomicConfig(&app.Config{})
+
+	RegisterBitbucketRoutes(mux, ac, nil, nil)
+
+	if _, pattern := mux.Handler(&http.Request{URL: &url.URL{Path: "/oauth/bitbucket"}}); pattern != "" {
+		t.Fatalf("expected no route registered without credentials, got pattern %q", pattern)
+	}
+}
+
+func TestRegisterBitbucketRoutes_WithCredentials(t *testing.T) {
+	mux := http.NewServeMux()
+	ac := app.NewAtomicConfig(&app.Config{
+		AuthNURL:                  mustParseURL(t, "https://authn.example.com"),
+		BitbucketOauthCredentials: &oauth.Credentials{ID: "id", Secret: "secret"},
+	})
+
+	RegisterBitbucketRoutes(mux, ac, nil, nil)
+
+	if _, pattern := mux.Handler(&http.Request{URL: &url.URL{Path: "/oauth/bitbucket"}}); pattern != "/oauth/bitbucket" {
+		t.Fatalf("expected /oauth/bitbucket to be registered, got pattern %q", pattern)
+	}
+	if _, pattern := mux.Handler(&http.Request{URL: &url.URL{Path: "/oauth/bitbucket/return"}}); pattern != "/oauth/bitbucket/return" {
+		t.Fatalf("expected /oauth/bitbucket/return to be registered, got pattern %q", pattern)
+	}
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", raw, err)
+	}
+	return u
+}
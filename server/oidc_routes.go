@@ -0,0 +1,141 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/keratin/authn-server/app"
+	"github.com/keratin/authn-server/data"
+	"github.com/keratin/authn-server/lib/oauth/oidc"
+	"github.com/keratin/authn-server/services"
+	"github.com/pkg/errors"
+)
+
+// RegisterOIDCRoutes wires a `/oauth/<name>` and `/oauth/<name>/return` route pair for every
+// provider configured via OIDC_PROVIDERS, reusing the same state/nonce cookie dance as AuthN's
+// other OAuth providers. The provider list, including each provider's client credentials, is
+// read once, at registration time, since adding or removing a provider is a structural change
+// that requires a restart either way; the handlers load ac fresh on every request for the
+// cookie settings, the redirect whitelist and ENABLE_SIGNUP, so they see whatever Config a
+// SECRETS_RELOAD hot-swap last stored.
+func RegisterOIDCRoutes(mux *http.ServeMux, ac *app.AtomicConfig, accounts data.AccountStore, sessions SessionIssuer) error {
+	for _, cfg := range ac.Load().OIDCProviders {
+		provider, err := oidc.NewProvider(cfg)
+		if err != nil {
+			return errors.Wrapf(err, "oidc.NewProvider(%s)", cfg.Name)
+		}
+
+		h := &oidcHandler{ac: ac, provider: provider, accounts: accounts, sessions: sessions}
+		mux.HandleFunc("/oauth/"+cfg.Name, h.begin)
+		mux.HandleFunc("/oauth/"+cfg.Name+"/return", h.finish)
+	}
+
+	return nil
+}
+
+type oidcHandler struct {
+	ac       *app.AtomicConfig
+	provider *oidc.Provider
+	accounts data.AccountStore
+	sessions SessionIssuer
+}
+
+func (h *oidcHandler) redirectURI(c *app.Config) string {
+	return strings.TrimSuffix(c.AuthNURL.String(), "/") + "/oauth/" + h.provider.Name + "/return"
+}
+
+func (h *oidcHandler) begin(w http.ResponseWriter, r *http.Request) {
+	c := h.ac.Load()
+
+	state, err := randomOAuthValue()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := randomOAuthValue()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     c.OAuthCookieName,
+		Value:    state + "." + nonce,
+		HttpOnly: true,
+		Secure:   c.ForceSSL,
+		Path:     "/",
+	})
+
+	http.Redirect(w, r, h.provider.AuthCodeURL(h.redirectURI(c), state, nonce), http.StatusFound)
+}
+
+func (h *oidcHandler) finish(w http.ResponseWriter, r *http.Request) {
+	c := h.ac.Load()
+
+	destination, ok := validateRedirect(c, w, r, "destination")
+	if !ok {
+		return
+	}
+
+	cookie, err := r.Cookie(c.OAuthCookieName)
+	if err != nil {
+		http.Error(w, "missing oauth cookie", http.StatusBadRequest)
+		return
+	}
+
+	parts := strings.SplitN(cookie.Value, ".", 2)
+	if len(parts) != 2 || parts[0] != r.URL.Query().Get("state") {
+		http.Error(w, "state mismatch", http.StatusBadRequest)
+		return
+	}
+	nonce := parts[1]
+
+	rawIDToken, accessToken, err := h.provider.Exchange(r.URL.Query().Get("code"), h.redirectURI(c))
+	if err != nil {
+		http.Error(w, "oauth exchange failed", http.StatusBadGateway)
+		return
+	}
+
+	idToken, err := h.provider.VerifyIDToken(rawIDToken, nonce)
+	if err != nil {
+		http.Error(w, "invalid id token", http.StatusUnauthorized)
+		return
+	}
+
+	// Prefer email, the same as AuthN's other social providers (see bitbucketHandler.finish),
+	// falling back to the ID token's subject claim for issuers that don't include one.
+	subject := idToken.Email
+	if subject == "" {
+		if info, err := h.provider.Userinfo(accessToken); err == nil {
+			subject = info.Email
+		}
+	}
+	if subject == "" {
+		subject = idToken.Subject
+	}
+
+	accountID, err := services.MachineAuthenticator(h.accounts, subject, c.EnableSignup)
+	if err != nil {
+		http.Error(w, "account lookup failed", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.sessions.IssueSession(w, r, accountID); err != nil {
+		http.Error(w, "failed to issue session", http.StatusInternalServerError)
+		return
+	}
+
+	if destination != "" {
+		http.Redirect(w, r, destination, http.StatusFound)
+	}
+}
+
+func randomOAuthValue() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
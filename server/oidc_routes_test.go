@@ -0,0 +1,21 @@
+package server
+
+import "testing"
+
+func TestRandomOAuthValue(t *testing.T) {
+	a, err := randomOAuthValue()
+	if err != nil {
+		t.Fatalf("randomOAuthValue: %v", err)
+	}
+	b, err := randomOAuthValue()
+	if err != nil {
+		t.Fatalf("randomOAuthValue: %v", err)
+	}
+
+	if a == "" {
+		t.Fatal("expected a non-empty value")
+	}
+	if a == b {
+		t.Fatalf("expected two distinct random values, got %q twice", a)
+	}
+}
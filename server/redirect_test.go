@@ -0,0 +1,90 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/keratin/authn-server/app"
+	"github.com/keratin/authn-server/lib/route"
+)
+
+type stubSessionIssuer struct {
+	issuedAccountID int
+}
+
+func (s *stubSessionIssuer) IssueSession(w http.ResponseWriter, r *http.Request, accountID int) error {
+	s.issuedAccountID = accountID
+	return nil
+}
+
+func testConfig(t *testing.T) *app.Config {
+	t.Helper()
+	return &app.Config{
+		RedirectWhitelist: []route.Domain{route.ParseDomain("allowed.example.com")},
+	}
+}
+
+func TestValidateRedirect_Empty(t *testing.T) {
+	c := testConfig(t)
+	r := httptest.NewRequest(http.MethodGet, "/login", nil)
+	w := httptest.NewRecorder()
+
+	target, ok := validateRedirect(c, w, r, "redirect_uri")
+	if !ok || target != "" {
+		t.Fatalf("got target=%q ok=%v, want empty target and ok=true", target, ok)
+	}
+}
+
+func TestValidateRedirect_Disallowed(t *testing.T) {
+	c := testConfig(t)
+	r := httptest.NewRequest(http.MethodGet, "/login?redirect_uri="+url.QueryEscape("https://evil.example.com/"), nil)
+	w := httptest.NewRecorder()
+
+	_, ok := validateRedirect(c, w, r, "redirect_uri")
+	if ok {
+		t.Fatal("expected a disallowed redirect target to fail validation")
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRedirectingAuthHandler_RedirectsOnSuccess(t *testing.T) {
+	ac := app.NewAtomicConfig(testConfig(t))
+	sessions := &stubSessionIssuer{}
+
+	handler := RedirectingAuthHandler(ac, "redirect_uri", func(r *http.Request) (int, error) {
+		return 42, nil
+	}, sessions)
+
+	r := httptest.NewRequest(http.MethodGet, "/login?redirect_uri="+url.QueryEscape("https://allowed.example.com/"), nil)
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusFound)
+	}
+	if sessions.issuedAccountID != 42 {
+		t.Errorf("issuedAccountID = %d, want 42", sessions.issuedAccountID)
+	}
+}
+
+func TestRedirectingAuthHandler_AuthenticationFailure(t *testing.T) {
+	ac := app.NewAtomicConfig(testConfig(t))
+	sessions := &stubSessionIssuer{}
+
+	handler := RedirectingAuthHandler(ac, "redirect_uri", func(r *http.Request) (int, error) {
+		return 0, errors.New("bad credentials")
+	}, sessions)
+
+	r := httptest.NewRequest(http.MethodGet, "/login", nil)
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
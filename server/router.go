@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/keratin/authn-server/app"
+	"github.com/keratin/authn-server/data"
+	"github.com/pkg/errors"
+)
+
+// NewRouter builds the http.Handler AuthN serves: the generic OIDC connectors from
+// OIDC_PROVIDERS, the Bitbucket OAuth provider, and a dedicated machine-auth endpoint for
+// TRUSTED_JWT_ISSUERS. The Google, GitHub, Facebook and Discord OAuth routes are not registered
+// here.
+//
+// ac is threaded through to every handler rather than a plain *app.Config, so that when
+// SECRETS_RELOAD is enabled a rotated credential reaches these handlers without restarting the
+// process. Which routes exist is still decided once, from ac's value at call time - adding or
+// removing an OAuth provider is a structural change that requires a restart either way.
+func NewRouter(ac *app.AtomicConfig, accounts data.AccountStore, sessions SessionIssuer) (http.Handler, error) {
+	mux := http.NewServeMux()
+
+	if err := RegisterOIDCRoutes(mux, ac, accounts, sessions); err != nil {
+		return nil, errors.Wrap(err, "RegisterOIDCRoutes")
+	}
+
+	RegisterBitbucketRoutes(mux, ac, accounts, sessions)
+
+	if err := RegisterTrustedJWTRoute(mux, ac, accounts, sessions); err != nil {
+		return nil, errors.Wrap(err, "RegisterTrustedJWTRoute")
+	}
+
+	return mux, nil
+}
+
+// Boot wraps c in an AtomicConfig, announces its IdentitySigningKey as the cluster's active key
+// via whichever SESSION_STORE backend is configured, and - if c.SecretsReload is set - starts
+// watching every mounted `_FILE` secret so that a rotated value is hot-swapped into the
+// AtomicConfig without a restart. The returned app.SecretWatcher is nil (and there is nothing to
+// close) when SECRETS_RELOAD is not enabled.
+func Boot(c *app.Config) (*app.AtomicConfig, *app.SecretWatcher, error) {
+	ac := app.NewAtomicConfig(c)
+
+	if err := app.AnnounceIdentityKey(c); err != nil {
+		return nil, nil, errors.Wrap(err, "app.AnnounceIdentityKey")
+	}
+
+	if !c.SecretsReload {
+		return ac, nil, nil
+	}
+
+	watcher, err := app.WatchSecrets(ac)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "app.WatchSecrets")
+	}
+
+	return ac, watcher, nil
+}
@@ -0,0 +1,22 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/keratin/authn-server/app"
+)
+
+func TestBoot_SecretsReloadDisabled(t *testing.T) {
+	c := &app.Config{}
+
+	ac, watcher, err := Boot(c)
+	if err != nil {
+		t.Fatalf("Boot() error = %v", err)
+	}
+	if watcher != nil {
+		t.Fatal("expected no SecretWatcher when SecretsReload is false")
+	}
+	if ac.Load() != c {
+		t.Fatal("expected the AtomicConfig to wrap the given Config")
+	}
+}
@@ -0,0 +1,14 @@
+// Package server wires AuthN's HTTP routes: OAuth providers (including the generic OIDC and
+// Bitbucket connectors), trusted-JWT machine authentication, and redirect validation.
+package server
+
+import "net/http"
+
+// SessionIssuer is the seam between a successful authentication (OAuth, OIDC, trusted JWT, or
+// password) and AuthN's normal session machinery: minting the access/refresh token pair and
+// setting the session cookie. Routes in this package depend on the interface, implemented by
+// AuthN's existing login/session code, rather than a concrete type, so that this package can be
+// wired up without an import cycle back into it.
+type SessionIssuer interface {
+	IssueSession(w http.ResponseWriter, r *http.Request, accountID int) error
+}
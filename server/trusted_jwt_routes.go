@@ -0,0 +1,96 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/keratin/authn-server/app"
+	"github.com/keratin/authn-server/data"
+	"github.com/keratin/authn-server/lib/trustedjwt"
+	"github.com/keratin/authn-server/services"
+	"github.com/pkg/errors"
+)
+
+// RegisterTrustedJWTRoute wires `POST /session/token` when TRUSTED_JWT_ISSUERS is configured,
+// letting machine users exchange a JWT minted by one of those issuers for AuthN's normal
+// access/refresh token pair without the interactive session flow.
+//
+// This is a dedicated route rather than middleware wrapping the whole router: an earlier version
+// wrapped every request in a handler that, on a matching bearer token, issued a session and
+// returned without calling the wrapped handler, so any unrelated request that happened to carry a
+// trusted bearer token (an OIDC return, a health check, anything) was hijacked before it could
+// reach its actual destination. Giving machine auth its own endpoint means it can only ever
+// consume requests addressed to it.
+//
+// The trusted issuer list itself is read once from ac, at registration time, since adding or
+// removing an issuer is a structural change that requires a restart either way.
+func RegisterTrustedJWTRoute(mux *http.ServeMux, ac *app.AtomicConfig, accounts data.AccountStore, sessions SessionIssuer) error {
+	c := ac.Load()
+	if len(c.TrustedJWTIssuers) == 0 {
+		return nil
+	}
+
+	verifier, err := trustedjwt.NewVerifier(c.TrustedJWTIssuers)
+	if err != nil {
+		return errors.Wrap(err, "trustedjwt.NewVerifier")
+	}
+
+	h := &trustedJWTHandler{ac: ac, verifier: verifier, accounts: accounts, sessions: sessions}
+	mux.HandleFunc("/session/token", h.exchange)
+
+	return nil
+}
+
+type trustedJWTHandler struct {
+	ac       *app.AtomicConfig
+	verifier *trustedjwt.Verifier
+	accounts data.AccountStore
+	sessions SessionIssuer
+}
+
+func (h *trustedJWTHandler) exchange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "missing bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	subject, matched, err := h.verifier.Authenticate(token)
+	if !matched {
+		http.Error(w, "untrusted issuer", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	accountID, err := services.MachineAuthenticator(h.accounts, subject, h.ac.Load().EnableSignup)
+	if err != nil {
+		http.Error(w, "account lookup failed", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.sessions.IssueSession(w, r, accountID); err != nil {
+		http.Error(w, "failed to issue session", http.StatusInternalServerError)
+		return
+	}
+}
+
+// bearerToken extracts the raw token from an `Authorization: Bearer <token>` header, or returns
+// "" if the header is missing or uses a different scheme.
+func bearerToken(r *http.Request) string {
+	const prefix = "Bearer "
+
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, prefix) {
+		return ""
+	}
+
+	return strings.TrimPrefix(header, prefix)
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/keratin/authn-server/app"
+	"github.com/keratin/authn-server/lib/trustedjwt"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func TestBearerToken(t *testing.T) {
+	cases := []struct {
+		header string
+		want   string
+	}{
+		{"Bearer abc.def.ghi", "abc.def.ghi"},
+		{"", ""},
+		{"Basic dXNlcjpwYXNz", ""},
+		{"Bearer ", ""},
+	}
+
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.header != "" {
+			r.Header.Set("Authorization", tc.header)
+		}
+
+		got := bearerToken(r)
+		if got != tc.want {
+			t.Errorf("bearerToken(%q) = %q, want %q", tc.header, got, tc.want)
+		}
+	}
+}
+
+// fakeIssuer serves just enough OIDC discovery and JWKS for lib/oauth/oidc.NewProvider to
+// succeed against it, and can sign tokens that its own published key will verify.
+type fakeIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+
+	fi := &fakeIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"authorization_endpoint": fi.server.URL + "/authorize",
+			"token_endpoint":         fi.server.URL + "/token",
+			"jwks_uri":               fi.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "test-key",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+	fi.server = httptest.NewServer(mux)
+
+	return fi
+}
+
+func (fi *fakeIssuer) signToken(t *testing.T, audience, subject string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": fi.server.URL,
+		"aud": audience,
+		"sub": subject,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "test-key"
+
+	signed, err := token.SignedString(fi.key)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return signed
+}
+
+func TestRegisterTrustedJWTRoute_DoesNotHijackOtherRoutes(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.server.Close()
+
+	ac := app.NewAtomicConfig(&app.Config{
+		TrustedJWTIssuers: []trustedjwt.Config{
+			{IssuerURL: issuer.server.URL, Audience: "my-audience"},
+		},
+	})
+
+	mux := http.NewServeMux()
+	otherRouteHit := false
+	mux.HandleFunc("/other", func(w http.ResponseWriter, r *http.Request) {
+		otherRouteHit = true
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	if err := RegisterTrustedJWTRoute(mux, ac, nil, nil); err != nil {
+		t.Fatalf("RegisterTrustedJWTRoute: %v", err)
+	}
+
+	token := issuer.signToken(t, "my-audience", "machine-1")
+	r := httptest.NewRequest(http.MethodGet, "/other", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	mux.ServeHTTP(w, r)
+
+	if !otherRouteHit {
+		t.Fatal("expected /other's own handler to run, but the trusted-JWT route intercepted it")
+	}
+	if w.Code != http.StatusTeapot {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusTeapot)
+	}
+}
+
+func TestTrustedJWTHandler_UntrustedIssuer(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.server.Close()
+
+	otherIssuer := newFakeIssuer(t)
+	defer otherIssuer.server.Close()
+
+	ac := app.NewAtomicConfig(&app.Config{
+		TrustedJWTIssuers: []trustedjwt.Config{
+			{IssuerURL: issuer.server.URL, Audience: "my-audience"},
+		},
+	})
+
+	mux := http.NewServeMux()
+	if err := RegisterTrustedJWTRoute(mux, ac, nil, nil); err != nil {
+		t.Fatalf("RegisterTrustedJWTRoute: %v", err)
+	}
+
+	token := otherIssuer.signToken(t, "my-audience", "machine-1")
+	r := httptest.NewRequest(http.MethodPost, "/session/token", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	mux.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestTrustedJWTHandler_MissingToken(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.server.Close()
+
+	ac := app.NewAtomicConfig(&app.Config{
+		TrustedJWTIssuers: []trustedjwt.Config{
+			{IssuerURL: issuer.server.URL, Audience: "my-audience"},
+		},
+	})
+
+	mux := http.NewServeMux()
+	if err := RegisterTrustedJWTRoute(mux, ac, nil, nil); err != nil {
+		t.Fatalf("RegisterTrustedJWTRoute: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/session/token", nil)
+	w := httptest.NewRecorder()
+
+	mux.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
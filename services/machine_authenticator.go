@@ -0,0 +1,32 @@
+package services
+
+import (
+	"github.com/keratin/authn-server/data"
+	"github.com/pkg/errors"
+)
+
+// MachineAuthenticator maps a verified subject claim from a trusted external JWT issuer to a
+// local account ID, auto-provisioning an account if enableSignup is true and none exists yet.
+// It is the bridge between lib/trustedjwt's token verification and AuthN's normal session
+// issuance, so that machine users authenticated by an external IdP get the same access/refresh
+// token pair an interactive login would produce.
+func MachineAuthenticator(store data.AccountStore, subject string, enableSignup bool) (int, error) {
+	account, err := store.FindByUsername(subject)
+	if err != nil {
+		return 0, errors.Wrap(err, "FindByUsername")
+	}
+	if account != nil {
+		return account.ID, nil
+	}
+
+	if !enableSignup {
+		return 0, FieldErrors{{"account", ErrNotFound}}
+	}
+
+	accountID, err := store.Create(subject, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "Create")
+	}
+
+	return accountID, nil
+}